@@ -0,0 +1,55 @@
+// Package apierror defines the API's error type and its RFC 7807
+// application/problem+json rendering. It is shared by main's handlers
+// and by middleware, such as internal/auth's session check, that must
+// reject a request before any handler runs and so cannot depend on the
+// main package's handler plumbing.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error is returned by handlers instead of being panicked. Code is a
+// stable, machine-readable identifier (e.g. "user.not_found") that
+// clients can switch on without parsing Message, which is meant for
+// humans and may change wording over time.
+type Error struct {
+	Code       string `json:"code"`
+	HTTPStatus int    `json:"-"`
+	Message    string `json:"message"`
+	Details    string `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func New(code string, status int, message string) *Error {
+	return &Error{Code: code, HTTPStatus: status, Message: message}
+}
+
+// problemDetails is an RFC 7807 application/problem+json body.
+type problemDetails struct {
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Code   string `json:"code,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Write renders err as application/problem+json. Any error that is not
+// an *Error is treated as an unexpected internal error and reported as
+// a 500 without leaking its message to the client.
+func Write(w http.ResponseWriter, err error) {
+	apiErr, ok := err.(*Error)
+	if !ok {
+		apiErr = New("internal", http.StatusInternalServerError, "internal server error")
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(apiErr.HTTPStatus)
+	_ = json.NewEncoder(w).Encode(problemDetails{
+		Title:  http.StatusText(apiErr.HTTPStatus),
+		Status: apiErr.HTTPStatus,
+		Code:   apiErr.Code,
+		Detail: apiErr.Message,
+	})
+}
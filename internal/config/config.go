@@ -0,0 +1,246 @@
+// Package config holds the application's runtime settings and lets
+// operators change them without a restart: SIGHUP reloads the backing
+// file from disk, and PATCH /api/v1/admin/config applies an in-memory
+// mutation guarded by a fingerprint so two concurrent admin edits can't
+// silently clobber each other.
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RateLimit bounds the requests a single client may make.
+type RateLimit struct {
+	RequestsPerMinute int `json:"requests_per_minute" yaml:"requests_per_minute"`
+	Burst             int `json:"burst" yaml:"burst"`
+}
+
+// Config is the full set of hot-reloadable settings.
+type Config struct {
+	// ListenAddr is read once at process startup; http.Server has no
+	// way to rebind an already-listening socket, so changing it via
+	// Reload or an admin update and observing it on Current() does not
+	// move the running listener. Changing it requires a restart.
+	ListenAddr            string    `json:"listen_addr" yaml:"listen_addr"`
+	StorageDriver         string    `json:"storage_driver" yaml:"storage_driver"`
+	StorageDSN            string    `json:"storage_dsn" yaml:"storage_dsn"`
+	RequestTimeoutSeconds int       `json:"request_timeout_seconds" yaml:"request_timeout_seconds"`
+	CORSOrigins           []string  `json:"cors_origins" yaml:"cors_origins"`
+	RateLimit             RateLimit `json:"rate_limit" yaml:"rate_limit"`
+	LogLevel              string    `json:"log_level" yaml:"log_level"`
+}
+
+// Default returns the settings the app falls back to when no config
+// file is present. ListenAddr, StorageDSN and RequestTimeoutSeconds
+// match the previous hardcoded values; the CORS origins and rate limit
+// are new and had no hardcoded counterpart.
+func Default() Config {
+	return Config{
+		ListenAddr:            ":3333",
+		StorageDriver:         "jsonfile",
+		StorageDSN:            "users.json",
+		RequestTimeoutSeconds: 60,
+		CORSOrigins:           []string{"*"},
+		RateLimit:             RateLimit{RequestsPerMinute: 300, Burst: 50},
+		LogLevel:              "info",
+	}
+}
+
+// ErrFingerprintMismatch is returned by DoLockedAction when the caller's
+// fingerprint no longer matches the current config, meaning someone
+// else changed it first.
+var ErrFingerprintMismatch = errors.New("config: fingerprint mismatch")
+
+// ConfigHandler owns the current Config and serializes updates to it.
+// A single instance should be shared by the whole process.
+type ConfigHandler struct {
+	path string
+
+	mu      sync.RWMutex
+	current Config
+
+	subMu sync.Mutex
+	subs  []chan Config
+}
+
+// Load reads path (YAML if its extension is .yaml/.yml, JSON otherwise)
+// into a ConfigHandler, applying env var overrides on top. A missing
+// file is not an error; Default() is used instead.
+func Load(path string) (*ConfigHandler, error) {
+	h := &ConfigHandler{path: path}
+
+	cfg, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	applyEnvOverrides(&cfg)
+
+	h.current = cfg
+
+	return h, nil
+}
+
+func readFile(path string) (Config, error) {
+	cfg := Default()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		err = yaml.Unmarshal(b, &cfg)
+	} else {
+		err = json.Unmarshal(b, &cfg)
+	}
+
+	return cfg, err
+}
+
+// applyEnvOverrides lets operators override individual fields without
+// editing the config file, e.g. for container deployments.
+func applyEnvOverrides(cfg *Config) {
+	if v := os.Getenv("LISTEN_ADDR"); v != "" {
+		cfg.ListenAddr = v
+	}
+	if v := os.Getenv("STORAGE_DRIVER"); v != "" {
+		cfg.StorageDriver = v
+	}
+	if v := os.Getenv("STORAGE_DSN"); v != "" {
+		cfg.StorageDSN = v
+	}
+	if v := os.Getenv("REQUEST_TIMEOUT_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.RequestTimeoutSeconds = n
+		}
+	}
+	if v := os.Getenv("CORS_ORIGINS"); v != "" {
+		cfg.CORSOrigins = strings.Split(v, ",")
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		cfg.LogLevel = v
+	}
+}
+
+// Reload re-reads the config file from disk, applies env overrides and
+// publishes the result to every subscriber. It is what a SIGHUP handler
+// calls.
+func (h *ConfigHandler) Reload() error {
+	cfg, err := readFile(h.path)
+	if err != nil {
+		return err
+	}
+	applyEnvOverrides(&cfg)
+
+	h.mu.Lock()
+	h.current = cfg
+	h.mu.Unlock()
+
+	h.publish(cfg)
+
+	return nil
+}
+
+// Current returns a copy of the current config.
+func (h *ConfigHandler) Current() Config {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.current
+}
+
+// Fingerprint returns the SHA-256 hex digest of the current config's
+// JSON serialization. Callers of DoLockedAction must present the
+// fingerprint they last read to prove their edit isn't based on stale
+// state.
+func (h *ConfigHandler) Fingerprint() (string, error) {
+	return fingerprint(h.Current())
+}
+
+func fingerprint(cfg Config) (string, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// DoLockedAction applies fn to the current config iff wantFingerprint
+// matches its current fingerprint, then publishes the result to every
+// subscriber. Callers should treat ErrFingerprintMismatch as a 409
+// Conflict: refetch the config and retry with the new fingerprint.
+func (h *ConfigHandler) DoLockedAction(wantFingerprint string, fn func(*Config) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	got, err := fingerprint(h.current)
+	if err != nil {
+		return err
+	}
+	if got != wantFingerprint {
+		return ErrFingerprintMismatch
+	}
+
+	updated := h.current
+	if err := fn(&updated); err != nil {
+		return err
+	}
+	h.current = updated
+
+	h.publish(updated)
+
+	return nil
+}
+
+// Subscribe returns a channel that receives the config every time it
+// changes, via Reload or DoLockedAction. The channel is buffered by
+// one and never closed; subscribers that fall behind only see the
+// latest value.
+func (h *ConfigHandler) Subscribe() <-chan Config {
+	ch := make(chan Config, 1)
+
+	h.subMu.Lock()
+	h.subs = append(h.subs, ch)
+	h.subMu.Unlock()
+
+	return ch
+}
+
+func (h *ConfigHandler) publish(cfg Config) {
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+
+	for _, ch := range h.subs {
+		select {
+		case ch <- cfg:
+		default:
+			// Buffer is full with a stale update the subscriber hasn't
+			// drained yet; drain it and replace it with cfg instead of
+			// blocking the writer or leaving the stale value queued.
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- cfg:
+			default:
+			}
+		}
+	}
+}
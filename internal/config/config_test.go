@@ -0,0 +1,114 @@
+package config
+
+import "testing"
+
+func TestDoLockedAction(t *testing.T) {
+	h := &ConfigHandler{current: Default()}
+
+	fp, err := h.Fingerprint()
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+
+	t.Run("stale fingerprint is rejected", func(t *testing.T) {
+		err := h.DoLockedAction("not-the-real-fingerprint", func(cfg *Config) error {
+			t.Fatal("fn should not run when the fingerprint is stale")
+			return nil
+		})
+		if err != ErrFingerprintMismatch {
+			t.Fatalf("got err %v, want ErrFingerprintMismatch", err)
+		}
+	})
+
+	t.Run("matching fingerprint applies the mutation", func(t *testing.T) {
+		err := h.DoLockedAction(fp, func(cfg *Config) error {
+			cfg.LogLevel = "debug"
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("DoLockedAction: %v", err)
+		}
+		if got := h.Current().LogLevel; got != "debug" {
+			t.Fatalf("got LogLevel %q, want %q", got, "debug")
+		}
+	})
+
+	t.Run("fingerprint moves on after a successful update", func(t *testing.T) {
+		if err := h.DoLockedAction(fp, func(cfg *Config) error { return nil }); err != ErrFingerprintMismatch {
+			t.Fatalf("got err %v, want ErrFingerprintMismatch for the now-stale fingerprint", err)
+		}
+	})
+}
+
+func TestDoLockedActionPublishesToSubscribers(t *testing.T) {
+	h := &ConfigHandler{current: Default()}
+	ch := h.Subscribe()
+
+	fp, err := h.Fingerprint()
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+
+	if err := h.DoLockedAction(fp, func(cfg *Config) error {
+		cfg.LogLevel = "debug"
+		return nil
+	}); err != nil {
+		t.Fatalf("DoLockedAction: %v", err)
+	}
+
+	select {
+	case cfg := <-ch:
+		if cfg.LogLevel != "debug" {
+			t.Fatalf("got published LogLevel %q, want %q", cfg.LogLevel, "debug")
+		}
+	default:
+		t.Fatal("expected a published config update, got none")
+	}
+}
+
+func TestPublishReplacesStaleValueRatherThanDroppingTheNewOne(t *testing.T) {
+	h := &ConfigHandler{current: Default()}
+	ch := h.Subscribe()
+
+	fp, err := h.Fingerprint()
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+
+	// First update fills the cap-1 channel and is never drained.
+	if err := h.DoLockedAction(fp, func(cfg *Config) error {
+		cfg.LogLevel = "debug"
+		return nil
+	}); err != nil {
+		t.Fatalf("DoLockedAction: %v", err)
+	}
+
+	fp, err = h.Fingerprint()
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+
+	// Second update arrives before the subscriber drains the first; it
+	// must replace the queued value rather than be dropped.
+	if err := h.DoLockedAction(fp, func(cfg *Config) error {
+		cfg.LogLevel = "error"
+		return nil
+	}); err != nil {
+		t.Fatalf("DoLockedAction: %v", err)
+	}
+
+	select {
+	case cfg := <-ch:
+		if cfg.LogLevel != "error" {
+			t.Fatalf("got published LogLevel %q, want %q (the newest value)", cfg.LogLevel, "error")
+		}
+	default:
+		t.Fatal("expected a published config update, got none")
+	}
+
+	select {
+	case cfg := <-ch:
+		t.Fatalf("expected only one queued update, got a second: %+v", cfg)
+	default:
+	}
+}
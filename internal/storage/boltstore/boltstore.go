@@ -0,0 +1,311 @@
+// Package boltstore implements storage.Storage on top of BoltDB
+// (go.etcd.io/bbolt), an embedded single-file key/value store.
+package boltstore
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/GAZIMAGomeDDD/go-app/internal/storage"
+)
+
+var usersBucket = []byte("users")
+
+// Store is a storage.Storage backed by a BoltDB file.
+type Store struct {
+	db *bbolt.DB
+}
+
+// Open opens (creating if necessary) the BoltDB file at path and
+// ensures the users bucket exists.
+func Open(path string) (*Store, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(usersBucket)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+func itob(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
+
+// view runs fn in a read-only transaction, giving up and returning
+// ctx.Err() if ctx is cancelled first. bbolt has no native notion of a
+// context, so a transaction already in flight still runs to completion
+// in the background; this only stops the caller from waiting on it
+// indefinitely, mirroring jsonfile.Store.lock.
+func (s *Store) view(ctx context.Context, fn func(*bbolt.Tx) error) error {
+	done := make(chan error, 1)
+	go func() { done <- s.db.View(fn) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// update is view's read-write counterpart.
+func (s *Store) update(ctx context.Context, fn func(*bbolt.Tx) error) error {
+	done := make(chan error, 1)
+	go func() { done <- s.db.Update(fn) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// findByEmail scans the users bucket within tx for email, so it can run
+// inside the same transaction as a write (CreateUser) as well as on its
+// own (GetUserByEmail).
+func findByEmail(tx *bbolt.Tx, email string) (storage.User, bool, error) {
+	var user storage.User
+	found := false
+
+	err := tx.Bucket(usersBucket).ForEach(func(_, v []byte) error {
+		if found {
+			return nil
+		}
+		var u storage.User
+		if err := json.Unmarshal(v, &u); err != nil {
+			return err
+		}
+		if u.Email == email {
+			user = u
+			found = true
+		}
+		return nil
+	})
+
+	return user, found, err
+}
+
+func (s *Store) GetUser(ctx context.Context, id string) (*storage.User, error) {
+	seq, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+
+	var user storage.User
+	found := false
+
+	err = s.view(ctx, func(tx *bbolt.Tx) error {
+		v := tx.Bucket(usersBucket).Get(itob(seq))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, storage.ErrNotFound
+	}
+
+	return &user, nil
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*storage.User, error) {
+	var user storage.User
+	found := false
+
+	err := s.view(ctx, func(tx *bbolt.Tx) error {
+		var err error
+		user, found, err = findByEmail(tx, email)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, storage.ErrNotFound
+	}
+
+	return &user, nil
+}
+
+// CreateUser checks email uniqueness and inserts the new user inside a
+// single db.Update, so two concurrent registrations for the same email
+// can't both pass the check before either has written: bbolt serializes
+// all writers, so whichever goroutine's transaction runs second sees
+// the first one's insert and fails with storage.ErrEmailTaken.
+func (s *Store) CreateUser(ctx context.Context, name, email, passwordHash string) (string, error) {
+	user := storage.User{
+		CreatedAt:    time.Now(),
+		DisplayName:  name,
+		Email:        email,
+		PasswordHash: passwordHash,
+		Role:         storage.RoleUser,
+	}
+	if err := user.Validate(); err != nil {
+		return "", err
+	}
+
+	var id string
+
+	err := s.update(ctx, func(tx *bbolt.Tx) error {
+		if _, found, err := findByEmail(tx, email); err != nil {
+			return err
+		} else if found {
+			return storage.ErrEmailTaken
+		}
+
+		b := tx.Bucket(usersBucket)
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		id = strconv.FormatUint(seq, 10)
+		user.ID = id
+
+		v, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(itob(seq), v)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, id, name string) error {
+	if err := (storage.User{DisplayName: name}).Validate(); err != nil {
+		return err
+	}
+
+	seq, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return storage.ErrNotFound
+	}
+
+	return s.update(ctx, func(tx *bbolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+
+		v := b.Get(itob(seq))
+		if v == nil {
+			return storage.ErrNotFound
+		}
+
+		var user storage.User
+		if err := json.Unmarshal(v, &user); err != nil {
+			return err
+		}
+		user.DisplayName = name
+
+		nv, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(itob(seq), nv)
+	})
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id string) error {
+	seq, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return storage.ErrNotFound
+	}
+
+	return s.update(ctx, func(tx *bbolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+
+		if b.Get(itob(seq)) == nil {
+			return storage.ErrNotFound
+		}
+
+		return b.Delete(itob(seq))
+	})
+}
+
+func (s *Store) SearchUsers(ctx context.Context, opts storage.SearchOptions) ([]storage.User, int, error) {
+	var all []storage.User
+
+	err := s.view(ctx, func(tx *bbolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(_, v []byte) error {
+			var u storage.User
+			if err := json.Unmarshal(v, &u); err != nil {
+				return err
+			}
+			all = append(all, u)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	q := strings.ToLower(opts.Query)
+
+	matched := all[:0:0]
+	for _, u := range all {
+		if q != "" &&
+			!strings.Contains(strings.ToLower(u.DisplayName), q) &&
+			!strings.Contains(strings.ToLower(u.Email), q) {
+			continue
+		}
+		matched = append(matched, u)
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		var less bool
+		switch opts.SortColumn {
+		case storage.SortByDisplayName:
+			less = matched[i].DisplayName < matched[j].DisplayName
+		case storage.SortByEmail:
+			less = matched[i].Email < matched[j].Email
+		default:
+			less = matched[i].CreatedAt.Before(matched[j].CreatedAt)
+		}
+		if opts.SortOrder == storage.SortDesc {
+			return !less
+		}
+		return less
+	})
+
+	total := len(matched)
+
+	start := opts.Offset
+	if start > total {
+		start = total
+	}
+	end := start + opts.Limit
+	if end > total {
+		end = total
+	}
+
+	return matched[start:end], total, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,240 @@
+// Package jsonfile implements storage.Storage on top of a single JSON
+// file. It is intended for local development and small deployments; the
+// whole user list is kept in memory and the file is rewritten on every
+// mutation.
+package jsonfile
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GAZIMAGomeDDD/go-app/internal/storage"
+)
+
+type document struct {
+	Increment int                    `json:"increment"`
+	List      map[string]storage.User `json:"list"`
+}
+
+// Store is a storage.Storage backed by a JSON file on disk. A single
+// Store must be shared by all callers (via Open); unlike the ad-hoc
+// UserStore this replaces, it is not safe to construct one per request,
+// since that re-reads and rewrites the file out from under concurrent
+// writers.
+type Store struct {
+	sem  chan struct{}
+	path string
+	doc  document
+}
+
+// Open loads path into memory, creating an empty document if it does
+// not exist yet.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		sem:  make(chan struct{}, 1),
+		path: path,
+		doc:  document{List: map[string]storage.User{}},
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &s.doc); err != nil {
+		return nil, err
+	}
+	if s.doc.List == nil {
+		s.doc.List = map[string]storage.User{}
+	}
+
+	return s, nil
+}
+
+// lock acquires the store's mutex, giving up if ctx is cancelled first
+// so a slow write never blocks an abandoned request indefinitely.
+func (s *Store) lock(ctx context.Context) error {
+	select {
+	case s.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Store) unlock() {
+	<-s.sem
+}
+
+func (s *Store) save() error {
+	b, err := json.Marshal(s.doc)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path, b, fs.ModePerm)
+}
+
+func (s *Store) GetUser(ctx context.Context, id string) (*storage.User, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	user, ok := s.doc.List[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	return &user, nil
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*storage.User, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	for _, u := range s.doc.List {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+
+	return nil, storage.ErrNotFound
+}
+
+func (s *Store) CreateUser(ctx context.Context, name, email, passwordHash string) (string, error) {
+	if err := s.lock(ctx); err != nil {
+		return "", err
+	}
+	defer s.unlock()
+
+	for _, u := range s.doc.List {
+		if u.Email == email {
+			return "", storage.ErrEmailTaken
+		}
+	}
+
+	user := storage.User{
+		CreatedAt:    time.Now(),
+		DisplayName:  name,
+		Email:        email,
+		PasswordHash: passwordHash,
+		Role:         storage.RoleUser,
+	}
+	if err := user.Validate(); err != nil {
+		return "", err
+	}
+
+	s.doc.Increment++
+	id := strconv.Itoa(s.doc.Increment)
+	user.ID = id
+	s.doc.List[id] = user
+
+	if err := s.save(); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, id, name string) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	user, ok := s.doc.List[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	user.DisplayName = name
+	if err := user.Validate(); err != nil {
+		return err
+	}
+
+	s.doc.List[id] = user
+
+	return s.save()
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id string) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	if _, ok := s.doc.List[id]; !ok {
+		return storage.ErrNotFound
+	}
+
+	delete(s.doc.List, id)
+
+	return s.save()
+}
+
+func (s *Store) SearchUsers(ctx context.Context, opts storage.SearchOptions) ([]storage.User, int, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, 0, err
+	}
+	defer s.unlock()
+
+	q := strings.ToLower(opts.Query)
+
+	matched := make([]storage.User, 0, len(s.doc.List))
+	for _, u := range s.doc.List {
+		if q != "" &&
+			!strings.Contains(strings.ToLower(u.DisplayName), q) &&
+			!strings.Contains(strings.ToLower(u.Email), q) {
+			continue
+		}
+		matched = append(matched, u)
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		var less bool
+		switch opts.SortColumn {
+		case storage.SortByDisplayName:
+			less = matched[i].DisplayName < matched[j].DisplayName
+		case storage.SortByEmail:
+			less = matched[i].Email < matched[j].Email
+		default:
+			less = matched[i].CreatedAt.Before(matched[j].CreatedAt)
+		}
+		if opts.SortOrder == storage.SortDesc {
+			return !less
+		}
+		return less
+	})
+
+	total := len(matched)
+
+	start := opts.Offset
+	if start > total {
+		start = total
+	}
+	end := start + opts.Limit
+	if end > total {
+		end = total
+	}
+
+	return matched[start:end], total, nil
+}
+
+// Close flushes any pending state. The JSON driver writes synchronously
+// on every mutation, so there is nothing to flush; it exists to satisfy
+// storage.Storage.
+func (s *Store) Close() error {
+	return nil
+}
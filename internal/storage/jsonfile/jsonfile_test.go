@@ -0,0 +1,85 @@
+package jsonfile
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/GAZIMAGomeDDD/go-app/internal/storage"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := Open(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	return s
+}
+
+func TestSearchUsersPagination(t *testing.T) {
+	s := openTestStore(t)
+
+	for i := 0; i < 5; i++ {
+		email := string(rune('a'+i)) + "@example.com"
+		if _, err := s.CreateUser(context.Background(), "user", email, "hash"); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		limit     int
+		offset    int
+		wantCount int
+		wantTotal int
+	}{
+		{name: "full page", limit: 10, offset: 0, wantCount: 5, wantTotal: 5},
+		{name: "offset within range", limit: 10, offset: 3, wantCount: 2, wantTotal: 5},
+		{name: "offset past end clamps to empty", limit: 10, offset: 100, wantCount: 0, wantTotal: 5},
+		{name: "limit smaller than total", limit: 2, offset: 0, wantCount: 2, wantTotal: 5},
+		{name: "limit past end clamps", limit: 10, offset: 4, wantCount: 1, wantTotal: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, total, err := s.SearchUsers(context.Background(), storage.SearchOptions{
+				Limit:  tt.limit,
+				Offset: tt.offset,
+			})
+			if err != nil {
+				t.Fatalf("SearchUsers: %v", err)
+			}
+			if len(users) != tt.wantCount {
+				t.Errorf("got %d users, want %d", len(users), tt.wantCount)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("got total %d, want %d", total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestSearchUsersFilterByQuery(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, err := s.CreateUser(context.Background(), "Alice", "alice@example.com", "hash"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := s.CreateUser(context.Background(), "Bob", "bob@example.com", "hash"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	users, total, err := s.SearchUsers(context.Background(), storage.SearchOptions{
+		Query: "alice",
+		Limit: 10,
+	})
+	if err != nil {
+		t.Fatalf("SearchUsers: %v", err)
+	}
+	if total != 1 || len(users) != 1 || users[0].DisplayName != "Alice" {
+		t.Fatalf("got %+v (total %d), want only Alice", users, total)
+	}
+}
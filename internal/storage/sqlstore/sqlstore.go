@@ -0,0 +1,359 @@
+// Package sqlstore implements storage.Storage on top of database/sql.
+// Postgres, MySQL and SQLite are supported by selecting the matching
+// driver name; schema migrations run once at Open time.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+
+	"github.com/GAZIMAGomeDDD/go-app/internal/storage"
+)
+
+// validSortColumns mirrors the whitelist in main.go; SearchUsers
+// checks against it directly since sortColumn is interpolated into a
+// raw SQL string and must never trust the caller alone.
+var validSortColumns = map[string]bool{
+	storage.SortByCreatedAt:   true,
+	storage.SortByDisplayName: true,
+	storage.SortByEmail:       true,
+}
+
+// schema is used for "sqlite", the only driver with both an
+// AUTOINCREMENT keyword and no length limit on a UNIQUE TEXT column.
+const schema = `
+CREATE TABLE IF NOT EXISTS users (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at    TIMESTAMP NOT NULL,
+	display_name  TEXT NOT NULL,
+	email         TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	role          TEXT NOT NULL
+);`
+
+// postgresSchema is used instead of schema when driverName is
+// "postgres": Postgres has no AUTOINCREMENT keyword and needs its own
+// serial primary key syntax.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id            SERIAL PRIMARY KEY,
+	created_at    TIMESTAMP NOT NULL,
+	display_name  TEXT NOT NULL,
+	email         TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	role          TEXT NOT NULL
+);`
+
+// mysqlSchema is used instead of schema when driverName is "mysql":
+// MySQL spells the auto-increment keyword differently, and rejects a
+// UNIQUE constraint on a bare TEXT column ("BLOB/TEXT column used in
+// key specification without a key length"), so email needs a sized
+// VARCHAR instead.
+const mysqlSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id            INTEGER PRIMARY KEY AUTO_INCREMENT,
+	created_at    TIMESTAMP NOT NULL,
+	display_name  TEXT NOT NULL,
+	email         VARCHAR(255) NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	role          TEXT NOT NULL
+);`
+
+// Store is a storage.Storage backed by a database/sql driver.
+type Store struct {
+	db         *sql.DB
+	driverName string
+
+	stmtGet        *sql.Stmt
+	stmtGetByEmail *sql.Stmt
+	stmtCreate     *sql.Stmt
+	stmtUpdate     *sql.Stmt
+	stmtDelete     *sql.Stmt
+}
+
+const userColumns = `id, created_at, display_name, email, password_hash, role`
+
+// Open connects to dsn using driverName ("postgres", "mysql" or
+// "sqlite"), runs the schema migration and prepares the statements used
+// by every subsequent call.
+func Open(driverName, dsn string) (*Store, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sqlstore: open %s: %w", driverName, err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("sqlstore: ping %s: %w", driverName, err)
+	}
+
+	var migration string
+	switch driverName {
+	case "postgres":
+		migration = postgresSchema
+	case "mysql":
+		migration = mysqlSchema
+	default:
+		migration = schema
+	}
+	if _, err := db.Exec(migration); err != nil {
+		return nil, fmt.Errorf("sqlstore: migrate: %w", err)
+	}
+
+	s := &Store{db: db, driverName: driverName}
+
+	if err := s.prepare(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Store) prepare() error {
+	var err error
+	s.stmtGet, err = s.db.Prepare(`SELECT ` + userColumns + ` FROM users WHERE id = ` + s.placeholder(1))
+	if err != nil {
+		return fmt.Errorf("sqlstore: prepare get: %w", err)
+	}
+
+	s.stmtGetByEmail, err = s.db.Prepare(`SELECT ` + userColumns + ` FROM users WHERE email = ` + s.placeholder(1))
+	if err != nil {
+		return fmt.Errorf("sqlstore: prepare get by email: %w", err)
+	}
+
+	s.stmtCreate, err = s.db.Prepare(s.insertUserQuery())
+	if err != nil {
+		return fmt.Errorf("sqlstore: prepare create: %w", err)
+	}
+
+	s.stmtUpdate, err = s.db.Prepare(fmt.Sprintf(
+		`UPDATE users SET display_name = %s WHERE id = %s`, s.placeholder(1), s.placeholder(2)))
+	if err != nil {
+		return fmt.Errorf("sqlstore: prepare update: %w", err)
+	}
+
+	s.stmtDelete, err = s.db.Prepare(`DELETE FROM users WHERE id = ` + s.placeholder(1))
+	if err != nil {
+		return fmt.Errorf("sqlstore: prepare delete: %w", err)
+	}
+
+	return nil
+}
+
+// insertUserQuery returns the RETURNING-id form for Postgres, since its
+// driver has no LastInsertId support.
+func (s *Store) insertUserQuery() string {
+	if s.driverName == "postgres" {
+		return fmt.Sprintf(
+			`INSERT INTO users (created_at, display_name, email, password_hash, role) VALUES (%s, %s, %s, %s, %s) RETURNING id`,
+			s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4), s.placeholder(5))
+	}
+	return `INSERT INTO users (created_at, display_name, email, password_hash, role) VALUES (?, ?, ?, ?, ?)`
+}
+
+// placeholder returns the positional bind marker for n in this driver's
+// dialect ($1 for Postgres, ? otherwise).
+func (s *Store) placeholder(n int) string {
+	if s.driverName == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+func (s *Store) GetUser(ctx context.Context, id string) (*storage.User, error) {
+	var u storage.User
+	row := s.stmtGet.QueryRowContext(ctx, id)
+	if err := row.Scan(&u.ID, &u.CreatedAt, &u.DisplayName, &u.Email, &u.PasswordHash, &u.Role); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*storage.User, error) {
+	var u storage.User
+	row := s.stmtGetByEmail.QueryRowContext(ctx, email)
+	if err := row.Scan(&u.ID, &u.CreatedAt, &u.DisplayName, &u.Email, &u.PasswordHash, &u.Role); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// isDuplicateKeyError reports whether err is the driver-specific error
+// for a violated UNIQUE constraint. CreateUser relies on this rather
+// than a GetUserByEmail check beforehand, since two concurrent inserts
+// can both pass a pre-check; only the database itself can serialize the
+// check against the write.
+func isDuplicateKeyError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == 2067 // SQLITE_CONSTRAINT_UNIQUE
+	}
+
+	return false
+}
+
+func (s *Store) CreateUser(ctx context.Context, name, email, passwordHash string) (string, error) {
+	user := storage.User{CreatedAt: time.Now(), DisplayName: name, Email: email}
+	if err := user.Validate(); err != nil {
+		return "", err
+	}
+
+	if s.driverName == "postgres" {
+		var id int64
+		err := s.stmtCreate.QueryRowContext(ctx, user.CreatedAt, name, email, passwordHash, storage.RoleUser).Scan(&id)
+		if isDuplicateKeyError(err) {
+			return "", storage.ErrEmailTaken
+		}
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d", id), nil
+	}
+
+	res, err := s.stmtCreate.ExecContext(ctx, user.CreatedAt, name, email, passwordHash, storage.RoleUser)
+	if isDuplicateKeyError(err) {
+		return "", storage.ErrEmailTaken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d", id), nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, id, name string) error {
+	if err := (storage.User{DisplayName: name}).Validate(); err != nil {
+		return err
+	}
+
+	res, err := s.stmtUpdate.ExecContext(ctx, name, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id string) error {
+	res, err := s.stmtDelete.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// SearchUsers builds its WHERE/ORDER BY/LIMIT clauses dynamically since
+// they depend on the caller-supplied filter and sort column; everything
+// except the sort column (validated by the caller against the Sort*
+// whitelist before reaching here) is bound as a query argument.
+func (s *Store) SearchUsers(ctx context.Context, opts storage.SearchOptions) ([]storage.User, int, error) {
+	where := ""
+	var args []interface{}
+	if opts.Query != "" {
+		like := "%" + opts.Query + "%"
+		// jsonfile and boltstore both match display_name/email
+		// case-insensitively; match that here too, since the same
+		// query must return the same results regardless of
+		// STORAGE_DRIVER. Postgres has native case-insensitive
+		// matching via ILIKE; MySQL and SQLite fold case with LOWER.
+		if s.driverName == "postgres" {
+			where = fmt.Sprintf(" WHERE display_name ILIKE %s OR email ILIKE %s", s.placeholder(1), s.placeholder(2))
+		} else {
+			where = fmt.Sprintf(" WHERE LOWER(display_name) LIKE LOWER(%s) OR LOWER(email) LIKE LOWER(%s)", s.placeholder(1), s.placeholder(2))
+		}
+		args = append(args, like, like)
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sortColumn := opts.SortColumn
+	if sortColumn == "" {
+		sortColumn = storage.SortByCreatedAt
+	}
+	// sortColumn is interpolated directly into the query below, so it
+	// must be checked against the whitelist here too rather than
+	// trusting the caller (main.go validates it as well, but this is
+	// the last line of defense against SQL injection via the column
+	// name if that check is ever missed or bypassed).
+	if !validSortColumns[sortColumn] {
+		return nil, 0, fmt.Errorf("sqlstore: invalid sort column %q", sortColumn)
+	}
+	order := "ASC"
+	if opts.SortOrder == storage.SortDesc {
+		order = "DESC"
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM users%s ORDER BY %s %s LIMIT %s OFFSET %s`,
+		userColumns, where, sortColumn, order, s.placeholder(len(args)+1), s.placeholder(len(args)+2))
+	args = append(args, opts.Limit, opts.Offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []storage.User
+	for rows.Next() {
+		var u storage.User
+		if err := rows.Scan(&u.ID, &u.CreatedAt, &u.DisplayName, &u.Email, &u.PasswordHash, &u.Role); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+
+	return users, total, rows.Err()
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,148 @@
+package sqlstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/GAZIMAGomeDDD/go-app/internal/storage"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := Open("sqlite", filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestSearchUsersPagination(t *testing.T) {
+	s := openTestStore(t)
+
+	for i := 0; i < 5; i++ {
+		email := string(rune('a'+i)) + "@example.com"
+		if _, err := s.CreateUser(context.Background(), "user", email, "hash"); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		limit     int
+		offset    int
+		wantCount int
+		wantTotal int
+	}{
+		{name: "full page", limit: 10, offset: 0, wantCount: 5, wantTotal: 5},
+		{name: "offset within range", limit: 10, offset: 3, wantCount: 2, wantTotal: 5},
+		{name: "offset past end clamps to empty", limit: 10, offset: 100, wantCount: 0, wantTotal: 5},
+		{name: "limit smaller than total", limit: 2, offset: 0, wantCount: 2, wantTotal: 5},
+		{name: "limit past end clamps", limit: 10, offset: 4, wantCount: 1, wantTotal: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, total, err := s.SearchUsers(context.Background(), storage.SearchOptions{
+				Limit:  tt.limit,
+				Offset: tt.offset,
+			})
+			if err != nil {
+				t.Fatalf("SearchUsers: %v", err)
+			}
+			if len(users) != tt.wantCount {
+				t.Errorf("got %d users, want %d", len(users), tt.wantCount)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("got total %d, want %d", total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestSearchUsersFilterByQueryIsCaseInsensitive(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, err := s.CreateUser(context.Background(), "Alice", "alice@example.com", "hash"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := s.CreateUser(context.Background(), "Bob", "bob@example.com", "hash"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	users, total, err := s.SearchUsers(context.Background(), storage.SearchOptions{
+		Query: "ALICE",
+		Limit: 10,
+	})
+	if err != nil {
+		t.Fatalf("SearchUsers: %v", err)
+	}
+	if total != 1 || len(users) != 1 || users[0].DisplayName != "Alice" {
+		t.Fatalf("got %+v (total %d), want only Alice", users, total)
+	}
+}
+
+func TestSearchUsersSort(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, err := s.CreateUser(context.Background(), "Charlie", "charlie@example.com", "hash"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := s.CreateUser(context.Background(), "Alice", "alice@example.com", "hash"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := s.CreateUser(context.Background(), "Bob", "bob@example.com", "hash"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	users, _, err := s.SearchUsers(context.Background(), storage.SearchOptions{
+		Limit:      10,
+		SortColumn: storage.SortByDisplayName,
+	})
+	if err != nil {
+		t.Fatalf("SearchUsers: %v", err)
+	}
+	if len(users) != 3 || users[0].DisplayName != "Alice" || users[1].DisplayName != "Bob" || users[2].DisplayName != "Charlie" {
+		t.Fatalf("got %+v, want Alice, Bob, Charlie in order", users)
+	}
+
+	users, _, err = s.SearchUsers(context.Background(), storage.SearchOptions{
+		Limit:      10,
+		SortColumn: storage.SortByDisplayName,
+		SortOrder:  storage.SortDesc,
+	})
+	if err != nil {
+		t.Fatalf("SearchUsers: %v", err)
+	}
+	if len(users) != 3 || users[0].DisplayName != "Charlie" || users[1].DisplayName != "Bob" || users[2].DisplayName != "Alice" {
+		t.Fatalf("got %+v, want Charlie, Bob, Alice in order", users)
+	}
+}
+
+func TestSearchUsersRejectsInvalidSortColumn(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, _, err := s.SearchUsers(context.Background(), storage.SearchOptions{
+		Limit:      10,
+		SortColumn: "role; DROP TABLE users;--",
+	}); err == nil {
+		t.Fatal("SearchUsers: expected an error for an unwhitelisted sort column, got nil")
+	}
+}
+
+func TestCreateUserDuplicateEmail(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, err := s.CreateUser(context.Background(), "Alice", "alice@example.com", "hash"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	if _, err := s.CreateUser(context.Background(), "Alice Two", "alice@example.com", "hash"); err != storage.ErrEmailTaken {
+		t.Fatalf("got err %v, want storage.ErrEmailTaken", err)
+	}
+}
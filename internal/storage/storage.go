@@ -0,0 +1,85 @@
+// Package storage defines the persistence contract used by the HTTP
+// handlers and the concrete drivers (jsonfile, sqlstore, boltstore) that
+// implement it.
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("user not found")
+	ErrDisplayNameEmpty = errors.New("display name must not be empty")
+	ErrEmailTaken       = errors.New("email already registered")
+)
+
+// Roles recognized by the ownership middleware. RoleAdmin bypasses the
+// per-user ownership checks enforced on PATCH/DELETE.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// User is the persisted representation of an account. ID is assigned by
+// the storage driver on creation and is opaque to callers. PasswordHash
+// is never serialized to JSON responses.
+type User struct {
+	ID           string    `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	DisplayName  string    `json:"display_name"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+}
+
+func (u User) Validate() error {
+	if u.DisplayName == "" {
+		return ErrDisplayNameEmpty
+	}
+
+	return nil
+}
+
+// Sort columns accepted by SearchOptions.SortColumn.
+const (
+	SortByCreatedAt   = "created_at"
+	SortByDisplayName = "display_name"
+	SortByEmail       = "email"
+)
+
+// Sort orders accepted by SearchOptions.SortOrder.
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// SearchOptions narrows, orders and paginates the results of
+// SearchUsers. Callers validate SortColumn and SortOrder against the
+// Sort* constants above. jsonfile and boltstore sort an unknown
+// SortColumn by created_at; sqlstore rejects it with an error. Every
+// SortOrder other than SortDesc sorts ascending.
+type SearchOptions struct {
+	Query      string
+	SortColumn string
+	SortOrder  string
+	Limit      int
+	Offset     int
+}
+
+// Storage is implemented by every persistence driver. Drivers are
+// responsible for their own concurrency control: callers may invoke any
+// method from multiple goroutines at once.
+type Storage interface {
+	GetUser(ctx context.Context, id string) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	CreateUser(ctx context.Context, name, email, passwordHash string) (string, error)
+	UpdateUser(ctx context.Context, id, name string) error
+	DeleteUser(ctx context.Context, id string) error
+	// SearchUsers returns the page of users matching opts along with
+	// the total number of users matching the filter (ignoring Limit
+	// and Offset), for building pagination metadata.
+	SearchUsers(ctx context.Context, opts SearchOptions) (users []User, total int, err error)
+	Close() error
+}
@@ -1,148 +1,208 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
-	"io/fs"
-	"io/ioutil"
+	"context"
+	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
+	"github.com/gorilla/securecookie"
+	"golang.org/x/time/rate"
+
+	"github.com/GAZIMAGomeDDD/go-app/internal/apierror"
+	"github.com/GAZIMAGomeDDD/go-app/internal/auth"
+	"github.com/GAZIMAGomeDDD/go-app/internal/config"
+	"github.com/GAZIMAGomeDDD/go-app/internal/storage"
+	"github.com/GAZIMAGomeDDD/go-app/internal/storage/boltstore"
+	"github.com/GAZIMAGomeDDD/go-app/internal/storage/jsonfile"
+	"github.com/GAZIMAGomeDDD/go-app/internal/storage/sqlstore"
 )
 
-const store = `users.json`
+const defaultJSONFile = `users.json`
 
-type (
-	User struct {
-		CreatedAt   time.Time `json:"created_at"`
-		DisplayName string    `json:"display_name"`
-		Email       string    `json:"email"`
-	}
-	UserList  map[string]User
-	UserStore struct {
-		sync.Mutex
-		Increment int      `json:"increment"`
-		List      UserList `json:"list"`
-	}
-)
+const defaultConfigPath = "config.json"
 
-var (
-	ErrUserNotFound           = errors.New("user not found")
-	ErrUserDisplayNameIsEmpty = errors.New(`display name must not be empty`)
-)
+// app holds the dependencies shared by every handler. A single instance
+// is constructed in main. Its store is swapped out in place (rather than
+// each handler opening its own copy) whenever cfg's storage settings
+// change, so operators can switch backends without a restart.
+type app struct {
+	cfg  *config.ConfigHandler
+	auth *auth.Manager
 
-func (u User) Validate() error {
-	if u.DisplayName == "" {
-		return ErrUserDisplayNameIsEmpty
-	}
+	storeMu  sync.RWMutex
+	store    storage.Storage
+	storeKey string
 
-	return nil
+	limiterMu sync.Mutex
+	limiter   *rate.Limiter
 }
 
-func (s *UserStore) saveToJSONFile() error {
-	b, err := json.Marshal(s)
-	if err != nil {
-		return err
-	}
-
-	if err = ioutil.WriteFile(store, b, fs.ModePerm); err != nil {
-		return err
-	}
+// currentStore returns the store currently in use. It may be swapped out
+// by a concurrent config change immediately after returning, so callers
+// should use the returned value for the whole request rather than
+// re-fetching it mid-handler.
+func (a *app) currentStore() storage.Storage {
+	a.storeMu.RLock()
+	defer a.storeMu.RUnlock()
 
-	return nil
+	return a.store
 }
 
-func (s *UserStore) GetUser(id string) (*User, error) {
-	s.Lock()
-	defer s.Unlock()
-
-	user, ok := s.List[id]
-	if !ok {
-		return nil, ErrUserNotFound
+// applyConfig reacts to a new config value from a SIGHUP reload or an
+// admin PATCH (main's initial load instead calls applyStorage directly,
+// since a bad storage config at startup must be fatal rather than
+// logged and ignored). A failure here is logged and the previous store
+// keeps serving requests; it is safe to call with an unchanged config,
+// since applyStorage only reopens the backend when the driver or DSN
+// actually changed.
+func (a *app) applyConfig(cfg config.Config) {
+	if err := a.applyStorage(cfg); err != nil {
+		log.Printf("config: %v", err)
 	}
-
-	return &user, nil
+	a.applyRateLimit(cfg)
 }
 
-func (s *UserStore) CreateUser(name string, email string) (string, error) {
-	s.Lock()
-	defer s.Unlock()
-
-	s.Increment++
-	user := User{
-		CreatedAt:   time.Now(),
-		DisplayName: name,
-		Email:       email,
+// applyStorage opens cfg's storage backend and swaps it in, unless the
+// driver and DSN are unchanged from the store already in use. It
+// returns an error rather than logging one itself, so main can treat
+// the initial open as fatal while applyConfig treats a later one as
+// recoverable.
+func (a *app) applyStorage(cfg config.Config) error {
+	key := cfg.StorageDriver + "|" + cfg.StorageDSN
+
+	a.storeMu.RLock()
+	unchanged := key == a.storeKey
+	a.storeMu.RUnlock()
+	if unchanged {
+		return nil
 	}
 
-	if err := user.Validate(); err != nil {
-		return "", ErrUserDisplayNameIsEmpty
+	newStore, err := newStorage(cfg.StorageDriver, cfg.StorageDSN)
+	if err != nil {
+		return fmt.Errorf("open storage driver %q: %w", cfg.StorageDriver, err)
 	}
 
-	id := strconv.Itoa(s.Increment)
-	s.List[id] = user
+	a.storeMu.Lock()
+	old := a.store
+	a.store = newStore
+	a.storeKey = key
+	a.storeMu.Unlock()
 
-	if err := s.saveToJSONFile(); err != nil {
-		return "", err
+	if old != nil {
+		_ = old.Close()
 	}
 
-	return id, nil
+	return nil
 }
 
-func (s *UserStore) UpdateUser(id, name string) error {
-	s.Lock()
-	defer s.Unlock()
+func (a *app) applyRateLimit(cfg config.Config) {
+	limiter := rate.NewLimiter(rate.Limit(float64(cfg.RateLimit.RequestsPerMinute)/60), cfg.RateLimit.Burst)
 
-	user, ok := s.List[id]
-	if !ok {
-		return ErrUserNotFound
-	}
+	a.limiterMu.Lock()
+	a.limiter = limiter
+	a.limiterMu.Unlock()
+}
 
-	user.DisplayName = name
-	if err := user.Validate(); err != nil {
-		return ErrUserDisplayNameIsEmpty
+// watchConfig applies every config published on ch, until ch is closed.
+func (a *app) watchConfig(ch <-chan config.Config) {
+	for cfg := range ch {
+		a.applyConfig(cfg)
 	}
+}
 
-	s.List[id] = user
+// sessionKeys reads the SESSION_HASH_KEY / SESSION_BLOCK_KEY env vars
+// used to sign and encrypt session cookies, generating ephemeral keys
+// if they are unset. Ephemeral keys mean sessions do not survive a
+// restart, which is fine for local development but must be overridden
+// in any deployment with more than one replica.
+func sessionKeys() (hashKey, blockKey []byte) {
+	hashKey = []byte(os.Getenv("SESSION_HASH_KEY"))
+	if len(hashKey) == 0 {
+		hashKey = securecookie.GenerateRandomKey(64)
+	}
 
-	if err := s.saveToJSONFile(); err != nil {
-		return err
+	blockKey = []byte(os.Getenv("SESSION_BLOCK_KEY"))
+	if len(blockKey) == 0 {
+		blockKey = securecookie.GenerateRandomKey(32)
 	}
 
-	return nil
+	return hashKey, blockKey
 }
 
-func (s *UserStore) DeleteUser(id string) error {
-	s.Lock()
-	defer s.Unlock()
+// newStorage selects a storage.Storage implementation based on driver.
+// dsn is interpreted per-driver (a file path for jsonfile/bolt, a
+// connection string for sql drivers).
+func newStorage(driver, dsn string) (storage.Storage, error) {
+	switch driver {
+	case "", "jsonfile":
+		if dsn == "" {
+			dsn = defaultJSONFile
+		}
+		return jsonfile.Open(dsn)
+	case "postgres", "mysql", "sqlite":
+		return sqlstore.Open(driver, dsn)
+	case "bolt", "boltdb":
+		return boltstore.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
 
-	_, ok := s.List[id]
-	if !ok {
-		return ErrUserNotFound
+func main() {
+	configPath := os.Getenv("CONFIG_PATH")
+	if configPath == "" {
+		configPath = defaultConfigPath
 	}
 
-	delete(s.List, id)
+	cfgHandler, err := config.Load(configPath)
+	if err != nil {
+		panic(err)
+	}
 
-	if err := s.saveToJSONFile(); err != nil {
-		return err
+	hashKey, blockKey := sessionKeys()
+	a := &app{cfg: cfgHandler, auth: auth.NewManager(hashKey, blockKey)}
+
+	if err := a.applyStorage(cfgHandler.Current()); err != nil {
+		panic(fmt.Errorf("initial storage setup: %w", err))
 	}
+	a.applyRateLimit(cfgHandler.Current())
+	defer func() {
+		if s := a.currentStore(); s != nil {
+			_ = s.Close()
+		}
+	}()
 
-	return nil
-}
+	go a.watchConfig(cfgHandler.Subscribe())
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := cfgHandler.Reload(); err != nil {
+				log.Printf("config: reload of %s failed: %v", configPath, err)
+			}
+		}
+	}()
 
-func main() {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(a.logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(a.cors)
+	r.Use(a.rateLimit)
+	r.Use(a.timeout)
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(time.Now().String()))
@@ -150,118 +210,406 @@ func main() {
 
 	r.Route("/api", func(r chi.Router) {
 		r.Route("/v1", func(r chi.Router) {
+			r.Route("/auth", func(r chi.Router) {
+				r.Post("/register", wrap(a.register))
+				r.Post("/login", wrap(a.login))
+			})
+
 			r.Route("/users", func(r chi.Router) {
-				r.Get("/", searchUsers)
-				r.Post("/", createUser)
+				r.Get("/", wrap(a.searchUsers))
 
 				r.Route("/{id}", func(r chi.Router) {
-					r.Get("/", getUser)
-					r.Patch("/", updateUser)
-					r.Delete("/", deleteUser)
+					r.Get("/", wrap(a.getUser))
+
+					r.Group(func(r chi.Router) {
+						r.Use(a.auth.RequireSession)
+						r.Use(a.requireOwnership)
+
+						r.Patch("/", wrap(a.updateUser))
+						r.Delete("/", wrap(a.deleteUser))
+					})
+				})
+			})
+
+			r.Route("/admin", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(a.auth.RequireSession)
+					r.Use(a.requireAdmin)
+
+					r.Get("/config", wrap(a.getConfig))
+					r.Patch("/config", wrap(a.adminUpdateConfig))
 				})
 			})
 		})
 	})
 
-	http.ListenAndServe(":3333", r)
+	srv := &http.Server{
+		Addr:         cfgHandler.Current().ListenAddr,
+		Handler:      r,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+			return
+		}
+		errCh <- nil
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			panic(err)
+		}
+		return
+	case <-stop:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		panic(err)
+	}
 }
 
-func searchUsers(w http.ResponseWriter, r *http.Request) {
-	f, _ := ioutil.ReadFile(store)
-	s := UserStore{}
-	_ = json.Unmarshal(f, &s)
+// timeout bounds every request to cfg's current RequestTimeoutSeconds, so
+// a config change takes effect on the next request without a restart. A
+// non-positive value disables the deadline.
+func (a *app) timeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := time.Duration(a.cfg.Current().RequestTimeoutSeconds) * time.Second
+		if d <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-	render.JSON(w, r, s.List)
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// cors reflects the request's Origin header back when it appears in
+// cfg's current CORSOrigins (or "*" is present), and answers preflight
+// OPTIONS requests directly.
+func (a *app) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origins := a.cfg.Current().CORSOrigins
+
+		if origin := r.Header.Get("Origin"); origin != "" && corsOriginAllowed(origins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Vary", "Origin")
+		}
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func corsOriginAllowed(origins []string, origin string) bool {
+	for _, o := range origins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimit enforces cfg's current RateLimit against a single process-wide
+// limiter shared by all clients. Reload or an admin PATCH replaces the
+// limiter so new rules apply immediately.
+func (a *app) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.limiterMu.Lock()
+		limiter := a.limiter
+		a.limiterMu.Unlock()
+
+		if limiter != nil && !limiter.Allow() {
+			writeError(w, r, newAPIError("rate_limit.exceeded", http.StatusTooManyRequests, "rate limit exceeded"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logger wraps middleware.Logger so it can be silenced by setting cfg's
+// LogLevel to "silent" without a restart.
+func (a *app) logger(next http.Handler) http.Handler {
+	verbose := middleware.Logger(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.cfg.Current().LogLevel == "silent" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		verbose.ServeHTTP(w, r)
+	})
+}
+
+// APIError is returned by handlerFunc handlers instead of being
+// panicked. It is an alias of apierror.Error so that auth.RequireSession
+// (which must reject requests before any handlerFunc runs, and so
+// cannot depend on main's handler plumbing) renders through the exact
+// same RFC 7807 body.
+type APIError = apierror.Error
+
+func newAPIError(code string, status int, message string) *APIError {
+	return apierror.New(code, status, message)
+}
+
+func invalidRequest(code, message string) *APIError {
+	return newAPIError(code, http.StatusBadRequest, message)
+}
+
+// mapStorageError translates an error returned by storage.Storage into
+// the APIError the caller should render. Callers that can narrow the
+// possible errors further (e.g. a create handler never sees
+// ErrNotFound) may still use this as a catch-all default case.
+func mapStorageError(err error) *APIError {
+	switch err {
+	case storage.ErrNotFound:
+		return newAPIError("user.not_found", http.StatusNotFound, "user not found")
+	case storage.ErrDisplayNameEmpty:
+		return invalidRequest("user.display_name.empty", "display name must not be empty")
+	case storage.ErrEmailTaken:
+		return invalidRequest("user.email.taken", "email is already registered")
+	default:
+		return newAPIError("internal", http.StatusInternalServerError, "internal server error")
+	}
+}
+
+// handlerFunc lets handlers return an error instead of rendering it
+// themselves (or, as before, panicking into middleware.Recoverer and
+// leaving the response and any in-flight store mutation in an
+// inconsistent state).
+type handlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// wrap adapts a handlerFunc to http.HandlerFunc, rendering any returned
+// error with writeError.
+func wrap(h handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h(w, r); err != nil {
+			writeError(w, r, err)
+		}
+	}
+}
+
+// writeError renders err as application/problem+json, via the same
+// apierror.Write auth.RequireSession uses, so every rejection in the
+// API (handler-returned or middleware-rejected) has the same body.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apierror.Write(w, err)
+}
+
+// requireOwnership rejects PATCH/DELETE requests where the session
+// user is neither the target user nor an admin. It must run after
+// auth.Manager.RequireSession, which populates the session user id.
+func (a *app) requireOwnership(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requesterID, _ := auth.UserID(r.Context())
+
+		targetID := chi.URLParam(r, "id")
+		if targetID != requesterID {
+			requester, err := a.currentStore().GetUser(r.Context(), requesterID)
+			if err != nil || requester.Role != storage.RoleAdmin {
+				writeError(w, r, newAPIError("user.forbidden", http.StatusForbidden, "you may not modify this user"))
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
 }
 
-type CreateUserRequest struct {
+// requireAdmin rejects requests from a session user who is not an
+// admin. It must run after auth.Manager.RequireSession.
+func (a *app) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requesterID, _ := auth.UserID(r.Context())
+
+		requester, err := a.currentStore().GetUser(r.Context(), requesterID)
+		if err != nil || requester.Role != storage.RoleAdmin {
+			writeError(w, r, newAPIError("user.forbidden", http.StatusForbidden, "admin role required"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type RegisterRequest struct {
 	DisplayName string `json:"display_name"`
 	Email       string `json:"email"`
+	Password    string `json:"password"`
 }
 
-func (c *CreateUserRequest) Bind(r *http.Request) error { return nil }
+func (c *RegisterRequest) Bind(r *http.Request) error { return nil }
+
+func (a *app) register(w http.ResponseWriter, r *http.Request) error {
+	defer r.Body.Close()
+
+	request := RegisterRequest{}
+	if err := render.Bind(r, &request); err != nil {
+		return invalidRequest("request.invalid_body", err.Error())
+	}
 
-func createUser(w http.ResponseWriter, r *http.Request) {
-	f, err := ioutil.ReadFile(store)
+	passwordHash, err := auth.HashPassword(request.Password)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	s := UserStore{}
-	err = json.Unmarshal(f, &s)
+	id, err := a.currentStore().CreateUser(r.Context(), request.DisplayName, request.Email, passwordHash)
 	if err != nil {
-		panic(err)
+		return mapStorageError(err)
 	}
 
-	defer r.Body.Close()
+	if err := a.auth.SetSession(w, id); err != nil {
+		return err
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, map[string]interface{}{
+		"user_id": id,
+	})
+
+	return nil
+}
+
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (c *LoginRequest) Bind(r *http.Request) error { return nil }
 
-	request := CreateUserRequest{}
+func (a *app) login(w http.ResponseWriter, r *http.Request) error {
+	defer r.Body.Close()
 
+	request := LoginRequest{}
 	if err := render.Bind(r, &request); err != nil {
-		err = render.Render(w, r, ErrInvalidRequest(
-			err,
-			http.StatusBadRequest,
-			http.StatusText(http.StatusBadRequest)),
-		)
-		if err != nil {
-			panic(err)
+		return invalidRequest("request.invalid_body", err.Error())
+	}
+
+	user, err := a.currentStore().GetUserByEmail(r.Context(), request.Email)
+	if err != nil || auth.ComparePassword(user.PasswordHash, request.Password) != nil {
+		return newAPIError("auth.invalid_credentials", http.StatusUnauthorized, "invalid email or password")
+	}
+
+	if err := a.auth.SetSession(w, user.ID); err != nil {
+		return err
+	}
+
+	render.Status(r, http.StatusNoContent)
+
+	return nil
+}
+
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+)
+
+var validSortColumns = map[string]bool{
+	storage.SortByCreatedAt:   true,
+	storage.SortByDisplayName: true,
+	storage.SortByEmail:       true,
+}
+
+// parseSearchOptions validates and converts the limit/offset/sort_column
+// /sort_order/q query parameters into a storage.SearchOptions.
+func parseSearchOptions(r *http.Request) (storage.SearchOptions, *APIError) {
+	q := r.URL.Query()
+
+	opts := storage.SearchOptions{
+		Query:      q.Get("q"),
+		SortColumn: storage.SortByCreatedAt,
+		SortOrder:  storage.SortAsc,
+		Limit:      defaultSearchLimit,
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 || limit > maxSearchLimit {
+			return opts, invalidRequest("search.limit.invalid",
+				fmt.Sprintf("limit must be an integer between 0 and %d", maxSearchLimit))
 		}
-		return
+		opts.Limit = limit
 	}
 
-	id, err := s.CreateUser(request.DisplayName, request.Email)
-	if err != nil {
-		switch err {
-		case ErrUserDisplayNameIsEmpty:
-			err = render.Render(w, r, ErrInvalidRequest(
-				ErrUserDisplayNameIsEmpty,
-				http.StatusBadRequest,
-				http.StatusText(http.StatusBadRequest)),
-			)
-			if err != nil {
-				panic(err)
-			}
-		default:
-			if err != nil {
-				panic(err)
-			}
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return opts, invalidRequest("search.offset.invalid", "offset must be a non-negative integer")
 		}
-		return
+		opts.Offset = offset
 	}
 
-	render.Status(r, http.StatusCreated)
-	render.JSON(w, r, map[string]interface{}{
-		"user_id": id,
-	})
+	if v := q.Get("sort_column"); v != "" {
+		if !validSortColumns[v] {
+			return opts, invalidRequest("search.sort_column.invalid", fmt.Sprintf("unknown sort_column %q", v))
+		}
+		opts.SortColumn = v
+	}
+
+	if v := q.Get("sort_order"); v != "" {
+		if v != storage.SortAsc && v != storage.SortDesc {
+			return opts, invalidRequest("search.sort_order.invalid",
+				fmt.Sprintf("sort_order must be %q or %q", storage.SortAsc, storage.SortDesc))
+		}
+		opts.SortOrder = v
+	}
+
+	return opts, nil
 }
 
-func getUser(w http.ResponseWriter, r *http.Request) {
-	f, err := ioutil.ReadFile(store)
-	if err != nil {
-		panic(err)
+func (a *app) searchUsers(w http.ResponseWriter, r *http.Request) error {
+	opts, apiErr := parseSearchOptions(r)
+	if apiErr != nil {
+		return apiErr
 	}
 
-	s := UserStore{}
-	err = json.Unmarshal(f, &s)
+	users, total, err := a.currentStore().SearchUsers(r.Context(), opts)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
+	render.JSON(w, r, map[string]interface{}{
+		"items":  users,
+		"total":  total,
+		"limit":  opts.Limit,
+		"offset": opts.Offset,
+	})
+
+	return nil
+}
+
+func (a *app) getUser(w http.ResponseWriter, r *http.Request) error {
 	id := chi.URLParam(r, "id")
 
-	user, err := s.GetUser(id)
+	user, err := a.currentStore().GetUser(r.Context(), id)
 	if err != nil {
-		err = render.Render(w, r, ErrInvalidRequest(
-			ErrUserNotFound,
-			http.StatusNotFound,
-			http.StatusText(http.StatusNotFound)),
-		)
-		if err != nil {
-			panic(err)
-		}
-		return
+		return mapStorageError(err)
 	}
+
 	render.JSON(w, r, user)
+
+	return nil
 }
 
 type UpdateUserRequest struct {
@@ -270,120 +618,119 @@ type UpdateUserRequest struct {
 
 func (c *UpdateUserRequest) Bind(r *http.Request) error { return nil }
 
-func updateUser(w http.ResponseWriter, r *http.Request) {
-	f, err := ioutil.ReadFile(store)
-	if err != nil {
-		panic(err)
-	}
-
-	s := UserStore{}
-	err = json.Unmarshal(f, &s)
-	if err != nil {
-		panic(err)
-	}
-
+func (a *app) updateUser(w http.ResponseWriter, r *http.Request) error {
 	request := UpdateUserRequest{}
 
 	if err := render.Bind(r, &request); err != nil {
-		err = render.Render(w, r, ErrInvalidRequest(
-			err,
-			http.StatusBadRequest,
-			http.StatusText(http.StatusBadRequest)),
-		)
-		if err != nil {
-			panic(err)
-		}
-		return
+		return invalidRequest("request.invalid_body", err.Error())
 	}
 
 	id := chi.URLParam(r, "id")
 
-	if err := s.UpdateUser(id, request.DisplayName); err != nil {
-		switch err {
-		case ErrUserDisplayNameIsEmpty:
-			err = render.Render(w, r, ErrInvalidRequest(
-				ErrUserDisplayNameIsEmpty,
-				http.StatusBadRequest,
-				http.StatusText(http.StatusBadRequest)),
-			)
-			if err != nil {
-				panic(err)
-			}
-		case ErrUserNotFound:
-			err = render.Render(w, r, ErrInvalidRequest(
-				ErrUserNotFound,
-				http.StatusNotFound,
-				http.StatusText(http.StatusNotFound)),
-			)
-			if err != nil {
-				panic(err)
-			}
-		default:
-			if err != nil {
-				panic(err)
-			}
-		}
-		return
+	if err := a.currentStore().UpdateUser(r.Context(), id, request.DisplayName); err != nil {
+		return mapStorageError(err)
 	}
 
 	render.Status(r, http.StatusNoContent)
-}
-
-func deleteUser(w http.ResponseWriter, r *http.Request) {
-	f, err := ioutil.ReadFile(store)
-	if err != nil {
-		panic(err)
-	}
 
-	s := UserStore{}
-	err = json.Unmarshal(f, &s)
-	if err != nil {
-		panic(err)
-	}
+	return nil
+}
 
+func (a *app) deleteUser(w http.ResponseWriter, r *http.Request) error {
 	id := chi.URLParam(r, "id")
 
-	if err := s.DeleteUser(id); err != nil {
-		switch err {
-		case ErrUserNotFound:
-			err = render.Render(w, r, ErrInvalidRequest(
-				ErrUserNotFound,
-				http.StatusNotFound,
-				http.StatusText(http.StatusNotFound)),
-			)
-			if err != nil {
-				panic(err)
-			}
-		default:
-			if err != nil {
-				panic(err)
-			}
-		}
-		return
+	if err := a.currentStore().DeleteUser(r.Context(), id); err != nil {
+		return mapStorageError(err)
 	}
 
 	render.Status(r, http.StatusNoContent)
-}
 
-type ErrResponse struct {
-	Err            error `json:"-"`
-	HTTPStatusCode int   `json:"-"`
+	return nil
+}
 
-	StatusText string `json:"status"`
-	AppCode    int64  `json:"code,omitempty"`
-	ErrorText  string `json:"error,omitempty"`
+// configResponse is what GET /api/v1/admin/config returns: the current
+// config plus the fingerprint a subsequent PATCH must echo back.
+type configResponse struct {
+	config.Config
+	Fingerprint string `json:"fingerprint"`
 }
 
-func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
-	render.Status(r, e.HTTPStatusCode)
+// getConfig returns the current config and its fingerprint, which the
+// caller must round-trip into adminUpdateConfig's request body. Without
+// this there is no way for a client to learn the fingerprint its first
+// PATCH (or a retry after a 409) needs to present.
+func (a *app) getConfig(w http.ResponseWriter, r *http.Request) error {
+	fingerprint, err := a.cfg.Fingerprint()
+	if err != nil {
+		return err
+	}
+
+	render.JSON(w, r, configResponse{Config: a.cfg.Current(), Fingerprint: fingerprint})
+
 	return nil
 }
 
-func ErrInvalidRequest(err error, status int, statusText string) render.Renderer {
-	return &ErrResponse{
-		Err:            err,
-		HTTPStatusCode: status,
-		StatusText:     statusText,
-		ErrorText:      err.Error(),
+// AdminConfigPatchRequest patches the fields present in the request over
+// the current config. Fingerprint must match the config's current
+// fingerprint, proving the caller's edit isn't based on stale state.
+// ListenAddr is deliberately not patchable here: srv.Addr is read once
+// at startup and the running listener never rebinds, so accepting it
+// would report success for a change that silently does nothing.
+type AdminConfigPatchRequest struct {
+	Fingerprint string `json:"fingerprint"`
+
+	StorageDriver         *string           `json:"storage_driver,omitempty"`
+	StorageDSN            *string           `json:"storage_dsn,omitempty"`
+	RequestTimeoutSeconds *int              `json:"request_timeout_seconds,omitempty"`
+	CORSOrigins           []string          `json:"cors_origins,omitempty"`
+	RateLimit             *config.RateLimit `json:"rate_limit,omitempty"`
+	LogLevel              *string           `json:"log_level,omitempty"`
+}
+
+func (c *AdminConfigPatchRequest) Bind(r *http.Request) error { return nil }
+
+// adminUpdateConfig applies the fields set in the request body to the
+// config atomically, rejecting the change with a 409 if Fingerprint is
+// stale. The updated config takes effect immediately via the same
+// subscription channel a SIGHUP reload publishes to.
+func (a *app) adminUpdateConfig(w http.ResponseWriter, r *http.Request) error {
+	defer r.Body.Close()
+
+	request := AdminConfigPatchRequest{}
+	if err := render.Bind(r, &request); err != nil {
+		return invalidRequest("request.invalid_body", err.Error())
 	}
+
+	err := a.cfg.DoLockedAction(request.Fingerprint, func(cfg *config.Config) error {
+		if request.StorageDriver != nil {
+			cfg.StorageDriver = *request.StorageDriver
+		}
+		if request.StorageDSN != nil {
+			cfg.StorageDSN = *request.StorageDSN
+		}
+		if request.RequestTimeoutSeconds != nil {
+			cfg.RequestTimeoutSeconds = *request.RequestTimeoutSeconds
+		}
+		if request.CORSOrigins != nil {
+			cfg.CORSOrigins = request.CORSOrigins
+		}
+		if request.RateLimit != nil {
+			cfg.RateLimit = *request.RateLimit
+		}
+		if request.LogLevel != nil {
+			cfg.LogLevel = *request.LogLevel
+		}
+		return nil
+	})
+	if err != nil {
+		if err == config.ErrFingerprintMismatch {
+			return newAPIError("config.fingerprint_mismatch", http.StatusConflict,
+				"config has changed since you last read it; refetch the fingerprint and retry")
+		}
+		return err
+	}
+
+	render.Status(r, http.StatusNoContent)
+
+	return nil
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/GAZIMAGomeDDD/go-app/internal/auth"
+	"github.com/GAZIMAGomeDDD/go-app/internal/storage"
+)
+
+// fakeStore is a minimal storage.Storage for exercising handlers and
+// middleware without a real backend.
+type fakeStore struct {
+	users map[string]storage.User
+}
+
+func (f *fakeStore) GetUser(_ context.Context, id string) (*storage.User, error) {
+	u, ok := f.users[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return &u, nil
+}
+
+func (f *fakeStore) GetUserByEmail(context.Context, string) (*storage.User, error) {
+	return nil, storage.ErrNotFound
+}
+
+func (f *fakeStore) CreateUser(context.Context, string, string, string) (string, error) {
+	return "", nil
+}
+
+func (f *fakeStore) UpdateUser(context.Context, string, string) error { return nil }
+
+func (f *fakeStore) DeleteUser(context.Context, string) error { return nil }
+
+func (f *fakeStore) SearchUsers(context.Context, storage.SearchOptions) ([]storage.User, int, error) {
+	return nil, 0, nil
+}
+
+func (f *fakeStore) Close() error { return nil }
+
+// sessionRequest builds a request carrying a valid session cookie for
+// userID, as auth.Manager.RequireSession expects.
+func sessionRequest(t *testing.T, mgr *auth.Manager, userID, targetID string) *http.Request {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	if err := mgr.SetSession(rec, userID); err != nil {
+		t.Fatalf("SetSession: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/users/"+targetID, nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", targetID)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	return req
+}
+
+func TestRequireOwnership(t *testing.T) {
+	hashKey, blockKey := sessionKeys()
+	a := &app{
+		auth: auth.NewManager(hashKey, blockKey),
+		store: &fakeStore{users: map[string]storage.User{
+			"1":     {ID: "1", Role: storage.RoleUser},
+			"2":     {ID: "2", Role: storage.RoleUser},
+			"admin": {ID: "admin", Role: storage.RoleAdmin},
+		}},
+	}
+
+	var reachedHandler bool
+	terminal := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reachedHandler = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := a.auth.RequireSession(a.requireOwnership(terminal))
+
+	tests := []struct {
+		name       string
+		sessionID  string
+		targetID   string
+		wantStatus int
+	}{
+		{name: "owner may modify self", sessionID: "1", targetID: "1", wantStatus: http.StatusNoContent},
+		{name: "admin may modify others", sessionID: "admin", targetID: "2", wantStatus: http.StatusNoContent},
+		{name: "non-owner non-admin is forbidden", sessionID: "1", targetID: "2", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reachedHandler = false
+
+			req := sessionRequest(t, a.auth, tt.sessionID, tt.targetID)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if reachedHandler != (tt.wantStatus == http.StatusNoContent) {
+				t.Fatalf("reachedHandler = %v, want %v", reachedHandler, tt.wantStatus == http.StatusNoContent)
+			}
+		})
+	}
+}